@@ -0,0 +1,153 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/subpop/vm/vm"
+)
+
+// buildCidataISO writes a NoCloud "cidata" seed ISO at path from the given
+// user-data, meta-data, and network-config contents. networkConfig may be
+// nil, in which case no network-config file is written.
+func buildCidataISO(path string, userData, metaData, networkConfig []byte) error {
+	dir, err := os.MkdirTemp("", "box-cidata-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "meta-data"), metaData, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "user-data"), userData, 0o644); err != nil {
+		return err
+	}
+	if networkConfig != nil {
+		if err := os.WriteFile(filepath.Join(dir, "network-config"), networkConfig, 0o644); err != nil {
+			return err
+		}
+	}
+
+	tool, err := isoTool()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-output", path, "-volid", "cidata", "-joliet", "-rock", dir}
+	cmd := exec.Command(tool, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v: %s", tool, err, out)
+	}
+
+	return nil
+}
+
+// isoTool locates genisoimage or mkisofs on PATH, preferring genisoimage.
+func isoTool() (string, error) {
+	for _, name := range []string{"genisoimage", "mkisofs"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("neither genisoimage nor mkisofs found on PATH")
+}
+
+// fileOrDefault reads path if it is non-empty, or returns fallback.
+func fileOrDefault(path, fallback string) ([]byte, error) {
+	if path == "" {
+		return []byte(fallback), nil
+	}
+	return os.ReadFile(path)
+}
+
+// cloudConfig is the subset of a #cloud-config document that buildUserData
+// merges a generated ssh_authorized_keys entry and mount write_files/runcmd
+// entries into, as opposed to blindly concatenating independently rendered
+// YAML onto a caller-supplied file.
+type cloudConfig map[string]interface{}
+
+// loadCloudConfig reads and parses the cloud-config at path, or returns an
+// empty document if path is empty.
+func loadCloudConfig(path string) (cloudConfig, error) {
+	content, err := fileOrDefault(path, "#cloud-config\n")
+	if err != nil {
+		return nil, err
+	}
+
+	var c cloudConfig
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("parse cloud-config %v: %w", path, err)
+	}
+	if c == nil {
+		c = cloudConfig{}
+	}
+	return c, nil
+}
+
+// appendList appends items to the list-valued key in c, creating it if
+// absent. It errors if the caller's cloud-config already declares key as
+// something other than a list, rather than silently clobbering it.
+func (c cloudConfig) appendList(key string, items ...interface{}) error {
+	existing, ok := c[key]
+	if !ok {
+		c[key] = items
+		return nil
+	}
+
+	list, ok := existing.([]interface{})
+	if !ok {
+		return fmt.Errorf("cloud-config key %q is not a list", key)
+	}
+	c[key] = append(list, items...)
+	return nil
+}
+
+// buildUserData reads the cloud-config at path, if any, and merges in an
+// ssh_authorized_keys entry for pubKey and, for each of mounts, a
+// write_files/runcmd entry that installs and enables its systemd mount
+// unit. Unlike concatenating independently rendered YAML fragments, keys
+// the caller's cloud-config already declares (e.g. its own write_files) are
+// extended rather than duplicated or shadowed.
+func buildUserData(path, pubKey string, mounts []vm.MountSpec) ([]byte, error) {
+	c, err := loadCloudConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.appendList("ssh_authorized_keys", pubKey); err != nil {
+		return nil, err
+	}
+
+	if len(mounts) > 0 {
+		var writeFiles, runcmd []interface{}
+		for _, m := range mounts {
+			unitName, content := vm.SystemdMountUnit(m)
+			writeFiles = append(writeFiles, map[string]interface{}{
+				"path":    "/etc/systemd/system/" + unitName,
+				"content": content,
+			})
+			runcmd = append(runcmd, "systemctl enable --now "+unitName)
+		}
+		runcmd = append([]interface{}{"systemctl daemon-reload"}, runcmd...)
+
+		if err := c.appendList("write_files", writeFiles...); err != nil {
+			return nil, err
+		}
+		if err := c.appendList("runcmd", runcmd...); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte("#cloud-config\n"), b...), nil
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"text/tabwriter"
 
-	"github.com/subpop/box"
-
+	"github.com/dustin/go-humanize"
 	"github.com/urfave/cli"
+
+	box "github.com/subpop/vm"
+	"github.com/subpop/vm/manifest"
+	"github.com/subpop/vm/vm"
 )
 
 func main() {
@@ -19,7 +24,21 @@ func main() {
 		{
 			Name: "create",
 			Action: func(c *cli.Context) error {
-				return box.Create(c.String("name"), c.String("image"))
+				mounts, err := parseMounts(c.StringSlice("mount"))
+				if err != nil {
+					return err
+				}
+
+				return box.Create(c.String("name"), c.String("image"), box.CreateOptions{
+					UserData:      c.String("user-data"),
+					MetaData:      c.String("meta-data"),
+					NetworkConfig: c.String("network-config"),
+					Ignition:      c.String("ignition"),
+					Mounts:        mounts,
+					CPUs:          uint(c.Int("cpus")),
+					MemoryMB:      uint64(c.Int("memory")),
+					DiskGB:        uint64(c.Int("disk-size")),
+				})
 			},
 			Flags: []cli.Flag{
 				cli.StringFlag{
@@ -29,6 +48,70 @@ func main() {
 					Name:     "i,image",
 					Required: true,
 				},
+				cli.StringFlag{
+					Name: "user-data",
+				},
+				cli.StringFlag{
+					Name: "meta-data",
+				},
+				cli.StringFlag{
+					Name: "network-config",
+				},
+				cli.StringFlag{
+					Name: "ignition",
+				},
+				cli.StringSliceFlag{
+					Name: "mount",
+				},
+				cli.IntFlag{
+					Name: "cpus",
+				},
+				cli.IntFlag{
+					Name: "memory",
+				},
+				cli.IntFlag{
+					Name: "disk-size",
+				},
+			},
+		},
+		{
+			Name: "mount",
+			Action: func(c *cli.Context) error {
+				m, err := vm.ParseMountSpec(c.String("mount"))
+				if err != nil {
+					return err
+				}
+				return box.Mount(c.String("name"), m)
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:     "name,n",
+					Required: true,
+				},
+				cli.StringFlag{
+					Name:     "mount",
+					Required: true,
+				},
+			},
+		},
+		{
+			Name: "unmount",
+			Action: func(c *cli.Context) error {
+				m, err := vm.ParseMountSpec(c.String("mount"))
+				if err != nil {
+					return err
+				}
+				return box.Unmount(c.String("name"), m)
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:     "name,n",
+					Required: true,
+				},
+				cli.StringFlag{
+					Name:     "mount",
+					Required: true,
+				},
 			},
 		},
 		{
@@ -100,6 +183,113 @@ func main() {
 				},
 			},
 		},
+		{
+			Name: "set",
+			Action: func(c *cli.Context) error {
+				return box.Set(c.String("name"), box.SetOptions{
+					CPUs:     uint(c.Int("cpus")),
+					MemoryMB: uint64(c.Int("memory")),
+					DiskGB:   uint64(c.Int("disk-size")),
+					Force:    c.Bool("force"),
+				})
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:     "name,n",
+					Required: true,
+				},
+				cli.IntFlag{
+					Name: "cpus",
+				},
+				cli.IntFlag{
+					Name: "memory",
+				},
+				cli.IntFlag{
+					Name: "disk-size",
+				},
+				cli.BoolFlag{
+					Name: "force,f",
+				},
+			},
+		},
+		{
+			Name: "snapshot",
+			Subcommands: []cli.Command{
+				{
+					Name: "create",
+					Action: func(c *cli.Context) error {
+						return vm.CreateSnapshot(c.String("name"), c.String("snapshot"), c.String("description"))
+					},
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "name,n",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:     "snapshot,s",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name: "description,d",
+						},
+					},
+				},
+				{
+					Name: "list",
+					Action: func(c *cli.Context) error {
+						snapshots, err := vm.ListSnapshots(c.String("name"))
+						if err != nil {
+							return err
+						}
+
+						w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+						fmt.Fprintln(w, "NAME\tAGE\tDESCRIPTION")
+						for _, s := range snapshots {
+							fmt.Fprintf(w, "%v\t%v\t%v\n", s.Name, humanize.Time(s.Created), s.Description)
+						}
+						return w.Flush()
+					},
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "name,n",
+							Required: true,
+						},
+					},
+				},
+				{
+					Name: "revert",
+					Action: func(c *cli.Context) error {
+						return vm.RevertSnapshot(c.String("name"), c.String("snapshot"))
+					},
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "name,n",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:     "snapshot,s",
+							Required: true,
+						},
+					},
+				},
+				{
+					Name: "delete",
+					Action: func(c *cli.Context) error {
+						return vm.DeleteSnapshot(c.String("name"), c.String("snapshot"))
+					},
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "name,n",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:     "snapshot,s",
+							Required: true,
+						},
+					},
+				},
+			},
+		},
 		{
 			Name: "connect",
 			Action: func(c *cli.Context) error {
@@ -120,6 +310,107 @@ func main() {
 				},
 			},
 		},
+		{
+			Name: "scp",
+			Action: func(c *cli.Context) error {
+				args := c.Args()
+				if len(args) != 2 {
+					return fmt.Errorf("usage: box scp -n NAME [-u USER] [--from] SRC DST")
+				}
+				local, remote := args.Get(0), args.Get(1)
+				if c.Bool("from") {
+					remote, local = args.Get(0), args.Get(1)
+				}
+				return box.Scp(c.String("name"), c.String("user"), local, remote, c.Bool("from"))
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:     "name,n",
+					Required: true,
+				},
+				cli.StringFlag{
+					Name:  "user,u",
+					Value: "root",
+				},
+				cli.BoolFlag{
+					Name: "from",
+				},
+			},
+		},
+		{
+			Name: "apply",
+			Action: func(c *cli.Context) error {
+				m, err := manifest.Load(c.String("file"))
+				if err != nil {
+					return err
+				}
+
+				changes, err := manifest.Plan(m, c.Bool("prune"))
+				if err != nil {
+					return err
+				}
+
+				return manifest.Apply(changes)
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:     "f,file",
+					Required: true,
+				},
+				cli.BoolFlag{
+					Name: "prune",
+				},
+			},
+		},
+		{
+			Name: "diff",
+			Action: func(c *cli.Context) error {
+				m, err := manifest.Load(c.String("file"))
+				if err != nil {
+					return err
+				}
+
+				changes, err := manifest.Plan(m, c.Bool("prune"))
+				if err != nil {
+					return err
+				}
+
+				printChanges(changes)
+				return nil
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:     "f,file",
+					Required: true,
+				},
+				cli.BoolFlag{
+					Name: "prune",
+				},
+			},
+		},
+		{
+			Name: "status",
+			Action: func(c *cli.Context) error {
+				m, err := manifest.Load(c.String("file"))
+				if err != nil {
+					return err
+				}
+
+				changes, err := manifest.Plan(m, false)
+				if err != nil {
+					return err
+				}
+
+				printChanges(changes)
+				return nil
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:     "f,file",
+					Required: true,
+				},
+			},
+		},
 		{
 			Name: "image",
 			Subcommands: []cli.Command{
@@ -186,8 +477,49 @@ func main() {
 		},
 	}
 
+	app.Commands = append(app.Commands, pluginListCommand)
+
+	builtin := map[string]bool{}
+	for _, cmd := range app.Commands {
+		builtin[cmd.Name] = true
+	}
+	requested := requestedCommand(os.Args)
+	needsPlugins := requested == "" || requested == "help" || requested == "h" || !builtin[requested]
+	if needsPlugins {
+		for _, p := range discoverPlugins() {
+			if p.Err != nil || builtin[p.Name] {
+				continue
+			}
+			app.Commands = append(app.Commands, pluginCommand(p))
+		}
+	}
+
 	err = app.Run(os.Args)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// parseMounts parses the repeated --mount flag values of box create.
+func parseMounts(specs []string) ([]vm.MountSpec, error) {
+	var mounts []vm.MountSpec
+	for _, s := range specs {
+		m, err := vm.ParseMountSpec(s)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// printChanges renders a manifest plan as a table of name, action, and
+// image.
+func printChanges(changes []manifest.Change) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tACTION\tIMAGE")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", c.Name, c.Action, c.Box.Image)
+	}
+	w.Flush()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// pluginMetadata is the JSON descriptor a box-<name> executable must print
+// in response to --box-cli-metadata.
+type pluginMetadata struct {
+	Name      string `json:"name"`
+	ShortHelp string `json:"short_help"`
+	Version   string `json:"version"`
+	Vendor    string `json:"vendor"`
+}
+
+// plugin pairs a discovered executable with the metadata it reported, or
+// the error encountered while probing it.
+type plugin struct {
+	Path string
+	pluginMetadata
+	Err error
+}
+
+// requestedCommand returns the subcommand name argv names (argv[1], skipping
+// global flags), or "" if argv names none (e.g. bare "box", which cli.App
+// resolves to its help action). main uses this to decide whether
+// discoverPlugins needs to run at all, since it execs --box-cli-metadata
+// against every box-* executable on $PATH; it must still run for "", "help",
+// and "h" so installed plugins show up in the command listing.
+func requestedCommand(argv []string) string {
+	for _, a := range argv[1:] {
+		if strings.HasPrefix(a, "-") {
+			continue
+		}
+		return a
+	}
+	return ""
+}
+
+// discoverPlugins scans $PATH and ~/.local/libexec/box/ for executables
+// named box-<name> and probes each with --box-cli-metadata.
+func discoverPlugins() []plugin {
+	var plugins []plugin
+	seen := map[string]bool{}
+
+	for _, dir := range pluginDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), "box-") {
+				continue
+			}
+
+			name := strings.TrimPrefix(entry.Name(), "box-")
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			path := filepath.Join(dir, entry.Name())
+			meta, err := probePlugin(path)
+			plugins = append(plugins, plugin{Path: path, pluginMetadata: meta, Err: err})
+		}
+	}
+
+	return plugins
+}
+
+// pluginDirs returns the directories searched for box-<name> executables,
+// in priority order.
+func pluginDirs() []string {
+	dirs := strings.Split(os.Getenv("PATH"), string(os.PathListSeparator))
+
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local", "libexec", "box"))
+	}
+
+	return dirs
+}
+
+// probePlugin executes path with --box-cli-metadata and parses its JSON
+// descriptor.
+func probePlugin(path string) (pluginMetadata, error) {
+	out, err := exec.Command(path, "--box-cli-metadata").Output()
+	if err != nil {
+		return pluginMetadata{}, err
+	}
+
+	var meta pluginMetadata
+	if err := json.Unmarshal(out, &meta); err != nil {
+		return pluginMetadata{}, fmt.Errorf("parse metadata: %w", err)
+	}
+
+	return meta, nil
+}
+
+// pluginCommand builds the top-level cli.Command that forwards argv and
+// environment to p's executable, including BOX_LIBVIRT_URI and, if the
+// invocation names a domain via -n/--name, BOX_VM_NAME.
+func pluginCommand(p plugin) cli.Command {
+	return cli.Command{
+		Name:            p.Name,
+		Usage:           p.ShortHelp,
+		SkipFlagParsing: true,
+		Action: func(c *cli.Context) error {
+			cmd := exec.Command(p.Path, c.Args()...)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			cmd.Env = append(os.Environ(), fmt.Sprintf("BOX_LIBVIRT_URI=%v", os.Getenv("BOX_LIBVIRT_URI")))
+			if name := pluginVMName(c.Args()); name != "" {
+				cmd.Env = append(cmd.Env, fmt.Sprintf("BOX_VM_NAME=%v", name))
+			}
+			return cmd.Run()
+		},
+	}
+}
+
+// pluginVMName scans args, the raw argv passed to a plugin, for a -n/--name
+// flag value, the convention every built-in box command uses to take a
+// domain name. It returns "" if none is present.
+func pluginVMName(args []string) string {
+	for i, a := range args {
+		switch {
+		case a == "-n" || a == "--name":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		case strings.HasPrefix(a, "--name="):
+			return strings.TrimPrefix(a, "--name=")
+		case strings.HasPrefix(a, "-n="):
+			return strings.TrimPrefix(a, "-n=")
+		}
+	}
+	return ""
+}
+
+// pluginListCommand is the built-in "box plugin list" command.
+var pluginListCommand = cli.Command{
+	Name: "plugin",
+	Subcommands: []cli.Command{
+		{
+			Name: "list",
+			Action: func(c *cli.Context) error {
+				for _, p := range discoverPlugins() {
+					if p.Err != nil {
+						fmt.Fprintf(os.Stderr, "%v: %v\n", p.Path, p.Err)
+						continue
+					}
+					fmt.Printf("%v\t%v\t%v\n", p.Name, p.Version, p.ShortHelp)
+				}
+				return nil
+			},
+		},
+	},
+}
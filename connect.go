@@ -0,0 +1,107 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/libvirt/libvirt-go"
+	"golang.org/x/term"
+
+	"github.com/subpop/vm/vm"
+)
+
+// Connect opens a remote session to the domain named name. mode is "ssh"
+// (the default) or "console". user is ignored in console mode.
+func Connect(name, mode, user string) error {
+	switch mode {
+	case "", "ssh":
+		return connectSSH(name, user)
+	case "console":
+		return connectConsole(name)
+	default:
+		return fmt.Errorf("unknown connect mode: %v", mode)
+	}
+}
+
+// connectSSH execs ssh against the domain's managed key and discovered
+// guest address, requiring no prior user setup.
+func connectSSH(name, user string) error {
+	c, err := loadConnection(name)
+	if err != nil {
+		return fmt.Errorf("load connection info for %v: %w", name, err)
+	}
+
+	addr, err := resolveAddress(name, c)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("ssh",
+		"-i", c.PrivateKeyPath,
+		"-o", "StrictHostKeyChecking=accept-new",
+		fmt.Sprintf("%v@%v", user, addr),
+	)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// connectConsole attaches the calling terminal to the domain's serial
+// console, putting stdin into raw mode for the duration.
+func connectConsole(name string) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	dom, err := conn.LookupDomainByName(name)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	fmt.Fprintln(os.Stderr, "Escape character is ^]")
+
+	return vm.Console(dom, os.Stdin, os.Stdout)
+}
+
+// resolveAddress returns c's cached guest address, discovering and caching
+// it first if it is not yet known.
+func resolveAddress(name string, c *Connection) (string, error) {
+	if c.Address != "" {
+		return c.Address, nil
+	}
+
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return "", err
+	}
+
+	dom, err := conn.LookupDomainByName(name)
+	if err != nil {
+		return "", err
+	}
+	defer dom.Free()
+
+	addr, err := vm.GuestAddress(dom)
+	if err != nil {
+		return "", err
+	}
+
+	c.Address = addr
+	if err := saveConnection(name, c); err != nil {
+		return "", err
+	}
+
+	return addr, nil
+}
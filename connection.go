@@ -0,0 +1,79 @@
+package box
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Connection records how to reach a domain created by Create: the private
+// key generated for it, and the most recently discovered guest address.
+type Connection struct {
+	PrivateKeyPath string `json:"private_key_path"`
+	Address        string `json:"address,omitempty"`
+}
+
+// connectionFilePath returns the path to the domain's connection file.
+func connectionFilePath(name string) (string, error) {
+	dir, err := boxDataDir(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "connection.json"), nil
+}
+
+// loadConnection reads the connection file for the domain named name.
+func loadConnection(name string) (*Connection, error) {
+	path, err := connectionFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Connection
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// saveConnection writes the connection file for the domain named name.
+func saveConnection(name string, c *Connection) error {
+	path, err := connectionFilePath(name)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o644)
+}
+
+// forgetAddress clears the cached guest address for the domain named name,
+// so the next Connect or Scp rediscovers it (from the guest agent or the
+// DHCP lease database) instead of reusing an address that may no longer be
+// valid. It is a no-op if the domain
+// has no connection file, e.g. because it was never created with Create.
+func forgetAddress(name string) error {
+	c, err := loadConnection(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if c.Address == "" {
+		return nil
+	}
+
+	c.Address = ""
+	return saveConnection(name, c)
+}
@@ -0,0 +1,189 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/dustinkirkland/golang-petname"
+	"github.com/google/uuid"
+	"github.com/libvirt/libvirt-go"
+
+	"github.com/subpop/vm/vm"
+)
+
+// CreateOptions configures an optional first-boot seed for a domain created
+// with Create. UserData, MetaData, and NetworkConfig are paths to NoCloud
+// cloud-init files and are combined into a single seed ISO attached as a
+// CDROM device alongside the domain's disk. Ignition is a path to an
+// Ignition config that is instead exposed to the guest via a fw_cfg entry.
+// Setting both UserData and Ignition is an error; when Ignition is set no
+// seed ISO is built, so MetaData and NetworkConfig are not used.
+// Mounts are virtiofs/9p shared folders attached at boot and mounted by a
+// generated systemd unit in the cloud-init seed.
+type CreateOptions struct {
+	CPUs          uint
+	MemoryMB      uint64
+	DiskGB        uint64
+	UserData      string
+	MetaData      string
+	NetworkConfig string
+	Ignition      string
+	Mounts        []vm.MountSpec
+}
+
+// Create defines a new domain named name, backed by a writable qcow2 disk
+// copy-on-write from the cached image named image. If name is empty, a
+// random two-word name is generated. The domain is defined but not started;
+// use Up to start it.
+func Create(name, image string, opts CreateOptions) error {
+	if opts.UserData != "" && opts.Ignition != "" {
+		return fmt.Errorf("conflicting arguments: user-data, ignition")
+	}
+
+	if name == "" {
+		name = petname.Generate(2, "-")
+	}
+	if opts.CPUs == 0 {
+		opts.CPUs = 2
+	}
+	if opts.MemoryMB == 0 {
+		opts.MemoryMB = 2048
+	}
+	if opts.DiskGB == 0 {
+		opts.DiskGB = 20
+	}
+
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	if !vm.SupportsVirtiofs(conn) {
+		for i, m := range opts.Mounts {
+			if m.Type == "virtiofs" {
+				m.Type = "9p"
+				opts.Mounts[i] = m
+			}
+		}
+	}
+
+	dataDir, err := boxDataDir(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return err
+	}
+
+	base, err := imagePath(image)
+	if err != nil {
+		return err
+	}
+
+	disk := filepath.Join(dataDir, "disk.qcow2")
+	if err := createDiskImage(disk, base, opts.DiskGB); err != nil {
+		return err
+	}
+
+	var seed, privKeyPath string
+	if opts.Ignition == "" {
+		pubKey, keyPath, err := generateSSHKeyPair(dataDir)
+		if err != nil {
+			return err
+		}
+		privKeyPath = keyPath
+
+		userData, err := buildUserData(opts.UserData, pubKey, opts.Mounts)
+		if err != nil {
+			return err
+		}
+
+		metaData, err := fileOrDefault(opts.MetaData, fmt.Sprintf("instance-id: iid-local01\nlocal-hostname: %v\n", name))
+		if err != nil {
+			return err
+		}
+
+		var networkConfig []byte
+		if opts.NetworkConfig != "" {
+			networkConfig, err = os.ReadFile(opts.NetworkConfig)
+			if err != nil {
+				return err
+			}
+		}
+
+		seed = filepath.Join(dataDir, "seed.iso")
+		if err := buildCidataISO(seed, userData, metaData, networkConfig); err != nil {
+			return err
+		}
+	} else {
+		// No automated key injection into the Ignition config: the
+		// generated key is recorded for Connect, but provisioning it is
+		// the Ignition config's responsibility.
+		_, keyPath, err := generateSSHKeyPair(dataDir)
+		if err != nil {
+			return err
+		}
+		privKeyPath = keyPath
+	}
+
+	desc, err := domainXML(domainParams{
+		Name:      name,
+		UUID:      uuid.New().String(),
+		CPUs:      opts.CPUs,
+		MemoryKiB: opts.MemoryMB * 1024,
+		Disk:      disk,
+		Seed:      seed,
+		Ignition:  opts.Ignition,
+		Mounts:    opts.Mounts,
+	})
+	if err != nil {
+		return err
+	}
+
+	dom, err := conn.DomainDefineXML(desc)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	return saveConnection(name, &Connection{PrivateKeyPath: privKeyPath})
+}
+
+// boxDataDir returns the per-domain state directory under
+// ~/.local/share/box, creating no part of the path.
+func boxDataDir(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "box", name), nil
+}
+
+// imagePath resolves a cached image name to the qcow2 file backing it, as
+// populated by ImageGet.
+func imagePath(image string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(home, ".local", "share", "box", "images", image+"-x86_64.qcow2")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("image %v not found, run `box image get -n %v` first", image, image)
+	}
+
+	return path, nil
+}
+
+// createDiskImage creates a qcow2 disk at path backed by base, grown to size
+// gigabytes.
+func createDiskImage(path, base string, size uint64) error {
+	cmd := exec.Command("qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", base, path, fmt.Sprintf("%dG", size))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("qemu-img create: %v: %s", err, out)
+	}
+	return nil
+}
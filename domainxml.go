@@ -0,0 +1,100 @@
+package box
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/subpop/vm/vm"
+)
+
+// domainParams holds the values substituted into the domain XML template
+// used by Create.
+type domainParams struct {
+	Name string
+	UUID string
+	CPUs uint
+	// MemoryKiB is the domain's memory size in KiB, matching libvirt's
+	// unit-less default for <memory>/<currentMemory>.
+	MemoryKiB uint64
+	Disk      string
+	// Seed, if set, is the path to a NoCloud seed ISO attached as a CDROM
+	// device alongside the disk.
+	Seed string
+	// Ignition, if set, is the path to an Ignition config exposed to the
+	// guest via a fw_cfg entry.
+	Ignition string
+	// Mounts are virtiofs/9p shared folders to attach at boot.
+	Mounts []vm.MountSpec
+}
+
+// rendered holds the raw XML fragments derived from domainParams that the
+// template can't compute on its own (conditional CPU/NUMA shape, shared
+// memory backing, one block per mount).
+type rendered struct {
+	domainParams
+	CPUXML           string
+	MemoryBackingXML string
+	FilesystemsXML   string
+}
+
+var domainTemplate = template.Must(template.New("domain").Parse(`<domain type='kvm'{{if .Ignition}} xmlns:qemu='http://libvirt.org/schemas/domain/qemu/1.0'{{end}}>
+  <name>{{.Name}}</name>
+  <uuid>{{.UUID}}</uuid>
+  <memory>{{.MemoryKiB}}</memory>
+  <currentMemory>{{.MemoryKiB}}</currentMemory>
+  <vcpu>{{.CPUs}}</vcpu>
+{{.MemoryBackingXML}}  <os>
+    <type arch='x86_64'>hvm</type>
+    <boot dev='hd'/>
+  </os>
+  <features>
+    <acpi/>
+    <apic/>
+  </features>
+{{.CPUXML}}  <devices>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source file='{{.Disk}}'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+{{if .Seed}}    <disk type='file' device='cdrom'>
+      <driver name='qemu' type='raw'/>
+      <source file='{{.Seed}}'/>
+      <target dev='sda' bus='sata'/>
+      <readonly/>
+    </disk>
+{{end}}{{.FilesystemsXML}}    <interface type='network'>
+      <source network='default'/>
+      <model type='virtio'/>
+    </interface>
+    <console type='pty'/>
+    <channel type='unix'>
+      <target type='virtio' name='org.qemu.guest_agent.0'/>
+    </channel>
+    <graphics type='vnc' port='-1' autoport='yes'/>
+  </devices>
+{{if .Ignition}}  <qemu:commandline>
+    <qemu:arg value='-fw_cfg'/>
+    <qemu:arg value='name=opt/com.coreos/config,file={{.Ignition}}'/>
+  </qemu:commandline>
+{{end}}</domain>
+`))
+
+// domainXML renders the domain definition used by Create.
+func domainXML(p domainParams) (string, error) {
+	r := rendered{domainParams: p, CPUXML: "  <cpu mode='host-passthrough'/>\n"}
+
+	if len(p.Mounts) > 0 {
+		r.MemoryBackingXML = vm.MemoryBackingXML()
+		r.CPUXML = vm.NUMAXML(p.MemoryKiB, p.CPUs)
+		for _, m := range p.Mounts {
+			r.FilesystemsXML += vm.FilesystemXML(m)
+		}
+	}
+
+	var b strings.Builder
+	if err := domainTemplate.Execute(&b, r); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,62 @@
+package box
+
+import (
+	"fmt"
+
+	"github.com/libvirt/libvirt-go"
+)
+
+// Down stops a running domain by name or id, without undefining it. A
+// graceful shutdown is requested unless force is set, in which case the
+// domain is destroyed immediately.
+func Down(name string, id int, force bool) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	if len(name) > 0 && id > 0 {
+		return fmt.Errorf("conflicting arguments: name, id")
+	}
+
+	var dom *libvirt.Domain
+	if name != "" {
+		dom, err = conn.LookupDomainByName(name)
+		if err != nil {
+			return err
+		}
+	} else if id > 0 {
+		dom, err = conn.LookupDomainById(uint32(id))
+		if err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("conflicting arguments: name, id")
+	}
+	defer dom.Free()
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return err
+	}
+	if state != libvirt.DOMAIN_RUNNING {
+		return nil
+	}
+
+	domName, err := dom.GetName()
+	if err != nil {
+		return err
+	}
+
+	if force {
+		if err := dom.Destroy(); err != nil {
+			return err
+		}
+	} else if err := dom.Shutdown(); err != nil {
+		return err
+	}
+
+	// The guest will get a new DHCP lease the next time it boots, so the
+	// cached address is no longer trustworthy.
+	return forgetAddress(domName)
+}
@@ -0,0 +1,289 @@
+package box
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+)
+
+// catalogImage describes a base image box knows how to fetch, as recorded
+// in the local catalog by ImageSync.
+type catalogImage struct {
+	Name string `json:"name"`
+	Arch string `json:"arch"`
+	URL  string `json:"url"`
+}
+
+// catalogURLEnv is the environment variable ImageSync reads the image
+// catalog URL from. box ships no built-in catalog: point it at a JSON
+// index of the form []catalogImage, hosted wherever the deployment's base
+// images are published.
+const catalogURLEnv = "BOX_IMAGE_CATALOG_URL"
+
+// imagesDir returns the directory holding the image catalog and cached
+// qcow2 base images, creating no part of the path.
+func imagesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "box", "images"), nil
+}
+
+// catalogPath returns the path to the local copy of the image catalog
+// fetched by ImageSync.
+func catalogPath() (string, error) {
+	dir, err := imagesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "catalog.json"), nil
+}
+
+// loadCatalog reads the local image catalog, as last populated by
+// ImageSync.
+func loadCatalog() ([]catalogImage, error) {
+	path, err := catalogPath()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no image catalog found, run `box image sync` first")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog []catalogImage
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// findCatalogImage returns the catalog entry matching name and arch, or an
+// error if no such image is known.
+func findCatalogImage(catalog []catalogImage, name, arch string) (catalogImage, error) {
+	for _, img := range catalog {
+		if img.Name == name && img.Arch == arch {
+			return img, nil
+		}
+	}
+	return catalogImage{}, fmt.Errorf("image %v (%v) not found, run `box image sync` to refresh the catalog", name, arch)
+}
+
+// ImageSync downloads the image catalog from BOX_IMAGE_CATALOG_URL,
+// replacing any previously cached copy. ImageInfo and ImageGet consult this
+// catalog to resolve an image name and architecture to a download URL.
+func ImageSync() error {
+	url := os.Getenv(catalogURLEnv)
+	if url == "" {
+		return fmt.Errorf("%v is not set: point it at a JSON image catalog to sync from", catalogURLEnv)
+	}
+
+	dir, err := imagesDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch image catalog: unexpected status %v", resp.Status)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var catalog []catalogImage
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		return fmt.Errorf("fetch image catalog: %v", err)
+	}
+
+	path, err := catalogPath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// ImageInfo prints the catalog entry for the named image and arch, and
+// whether it has already been fetched into the local cache.
+func ImageInfo(name, arch string) error {
+	catalog, err := loadCatalog()
+	if err != nil {
+		return err
+	}
+
+	img, err := findCatalogImage(catalog, name, arch)
+	if err != nil {
+		return err
+	}
+
+	dir, err := imagesDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, img.Name+"-"+img.Arch+".qcow2")
+
+	cached := "no"
+	if _, err := os.Stat(path); err == nil {
+		cached = "yes"
+	}
+
+	fmt.Printf("Name:   %v\n", img.Name)
+	fmt.Printf("Arch:   %v\n", img.Arch)
+	fmt.Printf("URL:    %v\n", img.URL)
+	fmt.Printf("Cached: %v\n", cached)
+
+	return nil
+}
+
+// ImageList prints a table of images cached in the local image directory.
+func ImageList() error {
+	dir, err := imagesDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		entries = nil
+	} else if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE")
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".qcow2" {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%v\t%v\n", strings.TrimSuffix(entry.Name(), ".qcow2"), humanize.Bytes(uint64(info.Size())))
+	}
+
+	return w.Flush()
+}
+
+// ImageGet downloads the named image and arch from the catalog into the
+// local image cache, where it is found by Create's imagePath lookup.
+func ImageGet(name, arch string) error {
+	catalog, err := loadCatalog()
+	if err != nil {
+		return err
+	}
+
+	img, err := findCatalogImage(catalog, name, arch)
+	if err != nil {
+		return err
+	}
+
+	dir, err := imagesDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, img.Name+"-"+img.Arch+".qcow2")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	resp, err := http.Get(img.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch image %v (%v): unexpected status %v", img.Name, img.Arch, resp.Status)
+	}
+
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// ImageRemove deletes every cached arch variant of the image named name. If
+// force is false, the user is prompted for confirmation first.
+func ImageRemove(name string, force bool) error {
+	dir, err := imagesDir()
+	if err != nil {
+		return err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, name+"-*.qcow2"))
+	if err != nil {
+		return err
+	}
+	// Keep only <name>-<arch>.qcow2: the glob also matches other images
+	// whose names begin with name+"-".
+	variants := matches[:0]
+	for _, path := range matches {
+		arch := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), name+"-"), ".qcow2")
+		if !strings.Contains(arch, "-") {
+			variants = append(variants, path)
+		}
+	}
+	matches = variants
+	if len(matches) == 0 {
+		return fmt.Errorf("image %v not cached", name)
+	}
+
+	if !force {
+		fmt.Printf("Are you sure you wish to remove %v? (y/N) ", name)
+		var response string
+		fmt.Scan(&response)
+		if strings.ToLower(strings.TrimSpace(response)) != "y" {
+			return nil
+		}
+	}
+
+	for _, path := range matches {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
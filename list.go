@@ -0,0 +1,90 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/libvirt/libvirt-go"
+)
+
+// List prints a table of defined domains to stdout. By default only active
+// (running) domains are shown; active and inactive independently widen the
+// result to include running and shut-off domains respectively.
+func List(active, inactive bool) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	if !active && !inactive {
+		active = true
+	}
+
+	var flags libvirt.ConnectListAllDomainsFlags
+	if active {
+		flags |= libvirt.CONNECT_LIST_DOMAINS_ACTIVE
+	}
+	if inactive {
+		flags |= libvirt.CONNECT_LIST_DOMAINS_INACTIVE
+	}
+
+	doms, err := conn.ListAllDomains(flags)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATE")
+	for _, dom := range doms {
+		id, err := dom.GetID()
+		if err != nil {
+			id = 0
+		}
+
+		name, err := dom.GetName()
+		if err != nil {
+			dom.Free()
+			return err
+		}
+
+		state, _, err := dom.GetState()
+		if err != nil {
+			dom.Free()
+			return err
+		}
+
+		idStr := "-"
+		if state == libvirt.DOMAIN_RUNNING {
+			idStr = fmt.Sprintf("%d", id)
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\n", idStr, name, domainStateString(state))
+		dom.Free()
+	}
+
+	return w.Flush()
+}
+
+// domainStateString renders a libvirt domain state the way `virsh list`
+// does.
+func domainStateString(state libvirt.DomainState) string {
+	switch state {
+	case libvirt.DOMAIN_RUNNING:
+		return "running"
+	case libvirt.DOMAIN_BLOCKED:
+		return "idle"
+	case libvirt.DOMAIN_PAUSED:
+		return "paused"
+	case libvirt.DOMAIN_SHUTDOWN:
+		return "in shutdown"
+	case libvirt.DOMAIN_SHUTOFF:
+		return "shut off"
+	case libvirt.DOMAIN_CRASHED:
+		return "crashed"
+	case libvirt.DOMAIN_PMSUSPENDED:
+		return "pmsuspended"
+	default:
+		return "unknown"
+	}
+}
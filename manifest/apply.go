@@ -0,0 +1,51 @@
+package manifest
+
+import (
+	box "github.com/subpop/vm"
+)
+
+// Apply executes changes in order, creating, updating, or destroying
+// domains via the same entry points the CLI uses directly.
+func Apply(changes []Change) error {
+	for _, change := range changes {
+		switch change.Action {
+		case ActionNone:
+			continue
+
+		case ActionCreate:
+			if err := box.Create(change.Box.Name, change.Box.Image, box.CreateOptions{
+				CPUs:          change.Box.CPUs,
+				MemoryMB:      change.Box.MemoryMB,
+				DiskGB:        change.Box.DiskGB,
+				UserData:      change.Box.UserData,
+				MetaData:      change.Box.MetaData,
+				NetworkConfig: change.Box.NetworkConfig,
+				Ignition:      change.Box.Ignition,
+			}); err != nil {
+				return err
+			}
+			if err := box.Up(change.Box.Name); err != nil {
+				return err
+			}
+
+		case ActionUpdate:
+			if err := box.Set(change.Name, box.SetOptions{
+				CPUs:     change.Box.CPUs,
+				MemoryMB: change.Box.MemoryMB,
+				Force:    true,
+			}); err != nil {
+				return err
+			}
+
+		case ActionDestroy:
+			if err := box.Down(change.Name, 0, true); err != nil {
+				return err
+			}
+			if err := box.Destroy(change.Name, 0, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
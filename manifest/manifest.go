@@ -0,0 +1,44 @@
+// Package manifest implements a declarative, Compose-like description of a
+// set of box domains, and the planning and execution needed to reconcile
+// libvirt's state to match it.
+package manifest
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest describes a set of domains to reconcile libvirt state against.
+type Manifest struct {
+	Boxes []Box `yaml:"boxes"`
+}
+
+// Box describes the desired configuration of a single domain.
+type Box struct {
+	Name          string   `yaml:"name"`
+	Image         string   `yaml:"image"`
+	CPUs          uint     `yaml:"cpus"`
+	MemoryMB      uint64   `yaml:"memory_mb"`
+	DiskGB        uint64   `yaml:"disk_gb"`
+	UserData      string   `yaml:"user_data"`
+	MetaData      string   `yaml:"meta_data"`
+	NetworkConfig string   `yaml:"network_config"`
+	Ignition      string   `yaml:"ignition"`
+	DependsOn     []string `yaml:"depends_on"`
+}
+
+// Load reads and parses the manifest at path.
+func Load(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
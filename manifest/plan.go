@@ -0,0 +1,186 @@
+package manifest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+
+	"github.com/libvirt/libvirt-go"
+)
+
+// Action describes what Apply must do to a domain to bring it in line with
+// its Box.
+type Action int
+
+const (
+	// ActionNone means the domain already matches its Box.
+	ActionNone Action = iota
+	// ActionCreate means the domain does not yet exist.
+	ActionCreate
+	// ActionUpdate means the domain exists but has drifted.
+	ActionUpdate
+	// ActionDestroy means the domain exists but is no longer in the
+	// manifest, and --prune was requested.
+	ActionDestroy
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionCreate:
+		return "create"
+	case ActionUpdate:
+		return "update"
+	case ActionDestroy:
+		return "destroy"
+	default:
+		return "none"
+	}
+}
+
+// Change is a single reconciliation step produced by Plan.
+type Change struct {
+	Name   string
+	Action Action
+	Box    Box
+}
+
+// domainResources is the subset of domain XML Plan inspects to detect
+// drift.
+type domainResources struct {
+	Memory uint64 `xml:"memory"`
+	VCPU   uint   `xml:"vcpu"`
+}
+
+// Plan compares m against the domains currently defined in libvirt and
+// returns the ordered set of changes needed to reconcile them, respecting
+// each Box's DependsOn. If prune is true, domains not present in m are
+// included as ActionDestroy changes.
+func Plan(m *Manifest, prune bool) ([]Change, error) {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]*libvirt.Domain{}
+	doms, err := conn.ListAllDomains(0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range doms {
+		name, err := doms[i].GetName()
+		if err != nil {
+			return nil, err
+		}
+		existing[name] = &doms[i]
+	}
+
+	ordered, err := order(m.Boxes)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	var changes []Change
+	for _, box := range ordered {
+		wanted[box.Name] = true
+
+		dom, ok := existing[box.Name]
+		if !ok {
+			changes = append(changes, Change{Name: box.Name, Action: ActionCreate, Box: box})
+			continue
+		}
+
+		drifted, err := driftsFrom(dom, box)
+		if err != nil {
+			return nil, err
+		}
+		if drifted {
+			changes = append(changes, Change{Name: box.Name, Action: ActionUpdate, Box: box})
+		} else {
+			changes = append(changes, Change{Name: box.Name, Action: ActionNone, Box: box})
+		}
+	}
+
+	if prune {
+		for name := range existing {
+			if !wanted[name] {
+				changes = append(changes, Change{Name: name, Action: ActionDestroy})
+			}
+		}
+	}
+
+	for i := range doms {
+		doms[i].Free()
+	}
+
+	return changes, nil
+}
+
+// driftsFrom reports whether dom's live vCPU or memory configuration
+// differs from box's. Disk capacity is not compared here; grow it directly
+// with `box set`.
+func driftsFrom(dom *libvirt.Domain, box Box) (bool, error) {
+	desc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return false, err
+	}
+
+	var r domainResources
+	if err := xml.Unmarshal([]byte(desc), &r); err != nil {
+		return false, err
+	}
+
+	if box.CPUs > 0 && box.CPUs != r.VCPU {
+		return true, nil
+	}
+
+	if box.MemoryMB > 0 && strconv.FormatUint(box.MemoryMB*1024, 10) != strconv.FormatUint(r.Memory, 10) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// order returns boxes sorted so that every box appears after the boxes it
+// depends on, via a simple stable topological sort. It returns an error if
+// DependsOn describes a cycle.
+func order(boxes []Box) ([]Box, error) {
+	byName := map[string]Box{}
+	for _, b := range boxes {
+		byName[b.Name] = b
+	}
+
+	var sorted []Box
+	visited := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var visit func(b Box) error
+	visit = func(b Box) error {
+		if visited[b.Name] {
+			return nil
+		}
+		if visiting[b.Name] {
+			return fmt.Errorf("cyclic depends_on involving box %v", b.Name)
+		}
+		visiting[b.Name] = true
+		for _, dep := range b.DependsOn {
+			if d, ok := byName[dep]; ok {
+				if err := visit(d); err != nil {
+					return err
+				}
+			}
+		}
+		visiting[b.Name] = false
+		visited[b.Name] = true
+		sorted = append(sorted, b)
+		return nil
+	}
+
+	for _, b := range boxes {
+		if err := visit(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
@@ -0,0 +1,65 @@
+package manifest
+
+import "testing"
+
+func TestOrderRespectsDependsOn(t *testing.T) {
+	boxes := []Box{
+		{Name: "web", DependsOn: []string{"db"}},
+		{Name: "db"},
+		{Name: "cache", DependsOn: []string{"db"}},
+	}
+
+	sorted, err := order(boxes)
+	if err != nil {
+		t.Fatalf("order() error = %v", err)
+	}
+
+	index := map[string]int{}
+	for i, b := range sorted {
+		index[b.Name] = i
+	}
+
+	if len(sorted) != len(boxes) {
+		t.Fatalf("order returned %d boxes, want %d", len(sorted), len(boxes))
+	}
+	if index["db"] > index["web"] {
+		t.Errorf("db must come before web, got order %v", names(sorted))
+	}
+	if index["db"] > index["cache"] {
+		t.Errorf("db must come before cache, got order %v", names(sorted))
+	}
+}
+
+func TestOrderIgnoresUnknownDependency(t *testing.T) {
+	boxes := []Box{
+		{Name: "web", DependsOn: []string{"missing"}},
+	}
+
+	sorted, err := order(boxes)
+	if err != nil {
+		t.Fatalf("order() error = %v", err)
+	}
+
+	if len(sorted) != 1 || sorted[0].Name != "web" {
+		t.Fatalf("order() = %v, want [web]", names(sorted))
+	}
+}
+
+func TestOrderDetectsCycle(t *testing.T) {
+	boxes := []Box{
+		{Name: "web", DependsOn: []string{"db"}},
+		{Name: "db", DependsOn: []string{"web"}},
+	}
+
+	if _, err := order(boxes); err == nil {
+		t.Fatal("order() error = nil, want cyclic dependency error")
+	}
+}
+
+func names(boxes []Box) []string {
+	var out []string
+	for _, b := range boxes {
+		out = append(out, b.Name)
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package box
+
+import (
+	"fmt"
+
+	"github.com/libvirt/libvirt-go"
+
+	"github.com/subpop/vm/vm"
+)
+
+// Mount live-attaches the shared folder described by m to the running
+// domain named name, and persists it in the domain's definition.
+func Mount(name string, m vm.MountSpec) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	if !vm.SupportsVirtiofs(conn) && m.Type == "virtiofs" {
+		m.Type = "9p"
+	}
+
+	dom, err := conn.LookupDomainByName(name)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	if m.Type == "virtiofs" {
+		ok, err := vm.HasSharedMemoryBacking(dom)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("domain %v was not created with --mount, so it lacks the shared memory backing and NUMA topology virtiofs requires; this cannot be hot-added, so recreate the domain with a mount or pass type=9p instead", name)
+		}
+	}
+
+	return dom.AttachDeviceFlags(vm.FilesystemXML(m), libvirt.DOMAIN_DEVICE_MODIFY_LIVE|libvirt.DOMAIN_DEVICE_MODIFY_CONFIG)
+}
+
+// Unmount live-detaches the shared folder described by m, which must match
+// the spec originally passed to Mount or Create, from the running domain
+// named name.
+func Unmount(name string, m vm.MountSpec) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	dom, err := conn.LookupDomainByName(name)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	return dom.DetachDeviceFlags(vm.FilesystemXML(m), libvirt.DOMAIN_DEVICE_MODIFY_LIVE|libvirt.DOMAIN_DEVICE_MODIFY_CONFIG)
+}
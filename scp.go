@@ -0,0 +1,39 @@
+package box
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Scp copies localPath to or from remotePath on the domain named name using
+// its managed key and discovered guest address. If download is true,
+// remotePath is copied to localPath; otherwise localPath is copied to
+// remotePath.
+func Scp(name, user, localPath, remotePath string, download bool) error {
+	c, err := loadConnection(name)
+	if err != nil {
+		return fmt.Errorf("load connection info for %v: %w", name, err)
+	}
+
+	addr, err := resolveAddress(name, c)
+	if err != nil {
+		return err
+	}
+
+	remote := fmt.Sprintf("%v@%v:%v", user, addr, remotePath)
+
+	args := []string{"-i", c.PrivateKeyPath, "-o", "StrictHostKeyChecking=accept-new"}
+	if download {
+		args = append(args, remote, localPath)
+	} else {
+		args = append(args, localPath, remote)
+	}
+
+	cmd := exec.Command("scp", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
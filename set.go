@@ -0,0 +1,354 @@
+package box
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/libvirt/libvirt-go"
+
+	"github.com/subpop/vm/vm"
+)
+
+// SetOptions describes the reconfiguration to apply to a domain. A zero value
+// for CPUs, MemoryMB, or DiskGB means "leave unchanged".
+type SetOptions struct {
+	CPUs     uint
+	MemoryMB uint64
+	DiskGB   uint64
+	Force    bool
+}
+
+// Set reconfigures the vCPU count, memory size, and/or disk capacity of the
+// domain named by name. Disk growth is applied to the domain's first file
+// backed disk via qemu-img resize before the domain is redefined. A running
+// domain is left untouched unless opts.Force is set, in which case it is
+// shut down, resized, redefined, and started again.
+func Set(name string, opts SetOptions) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	dom, err := conn.LookupDomainByName(name)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return err
+	}
+
+	running := state == libvirt.DOMAIN_RUNNING
+	if running && !opts.Force {
+		return fmt.Errorf("domain %v is running: use --force to shut down, resize, and restart it", name)
+	}
+
+	if running {
+		if err := shutdownAndWait(dom); err != nil {
+			return err
+		}
+		// The guest will get a new DHCP lease on restart, so the cached
+		// address is no longer trustworthy.
+		if err := forgetAddress(name); err != nil {
+			return err
+		}
+	}
+
+	desc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return err
+	}
+
+	if opts.CPUs > 0 {
+		desc, err = setElementText(desc, "vcpu", strconv.FormatUint(uint64(opts.CPUs), 10))
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.MemoryMB > 0 {
+		kib := strconv.FormatUint(opts.MemoryMB*1024, 10)
+		desc, err = setElementText(desc, "memory", kib)
+		if err != nil {
+			return err
+		}
+		desc, err = setElementText(desc, "currentMemory", kib)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.CPUs > 0 || opts.MemoryMB > 0 {
+		hasCell, err := hasNUMACell(desc)
+		if err != nil {
+			return err
+		}
+		if hasCell {
+			desc, err = resizeNUMACell(desc)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.DiskGB > 0 {
+		path, err := firstDiskSource(desc)
+		if err != nil {
+			return err
+		}
+
+		current, err := diskVirtualSize(path)
+		if err != nil {
+			return err
+		}
+		requested := opts.DiskGB * (1 << 30)
+		if requested <= current {
+			return fmt.Errorf("requested disk size %dG is not larger than the current size (%d bytes): box set only grows disks", opts.DiskGB, current)
+		}
+
+		if err := growDisk(path, opts.DiskGB); err != nil {
+			return err
+		}
+	}
+
+	newDom, err := conn.DomainDefineXML(desc)
+	if err != nil {
+		return err
+	}
+	defer newDom.Free()
+
+	if running {
+		if err := newDom.Create(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// shutdownAndWait requests a graceful shutdown of dom and polls its state
+// until it is no longer running.
+func shutdownAndWait(dom *libvirt.Domain) error {
+	if err := dom.Shutdown(); err != nil {
+		return err
+	}
+
+	for i := 0; i < 60; i++ {
+		state, _, err := dom.GetState()
+		if err != nil {
+			return err
+		}
+		if state != libvirt.DOMAIN_RUNNING {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("timed out waiting for domain to shut down")
+}
+
+// growDisk resizes the disk image at path to size gigabytes using qemu-img.
+func growDisk(path string, size uint64) error {
+	cmd := exec.Command("qemu-img", "resize", path, fmt.Sprintf("%dG", size))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("qemu-img resize: %v: %s", err, out)
+	}
+	return nil
+}
+
+// diskVirtualSize returns the current virtual size, in bytes, of the disk
+// image at path, as reported by qemu-img info.
+func diskVirtualSize(path string) (uint64, error) {
+	cmd := exec.Command("qemu-img", "info", "--output=json", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("qemu-img info: %v", err)
+	}
+
+	var info struct {
+		VirtualSize uint64 `json:"virtual-size"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return 0, fmt.Errorf("qemu-img info: parse output: %v", err)
+	}
+
+	return info.VirtualSize, nil
+}
+
+// firstDiskSource returns the backing file path of the first file-based disk
+// device in a domain XML description.
+func firstDiskSource(desc string) (string, error) {
+	type source struct {
+		File string `xml:"file,attr"`
+	}
+	type disk struct {
+		Device string `xml:"device,attr"`
+		Source source `xml:"source"`
+	}
+	type devices struct {
+		Disks []disk `xml:"disk"`
+	}
+	type domain struct {
+		Devices devices `xml:"devices"`
+	}
+
+	var d domain
+	if err := xml.Unmarshal([]byte(desc), &d); err != nil {
+		return "", err
+	}
+
+	for _, disk := range d.Devices.Disks {
+		if disk.Device == "disk" && disk.Source.File != "" {
+			return disk.Source.File, nil
+		}
+	}
+
+	return "", fmt.Errorf("no file-backed disk found")
+}
+
+// domainResources is the subset of domain XML used to recompute a NUMA
+// cell's cpus/memory attributes after a vcpu or memory change.
+type domainResources struct {
+	Memory uint64 `xml:"memory"`
+	VCPU   uint   `xml:"vcpu"`
+}
+
+// hasNUMACell reports whether desc declares a NUMA cell, which Create adds
+// for domains with virtiofs/9p mounts attached.
+func hasNUMACell(desc string) (bool, error) {
+	var d struct {
+		Cell *struct{} `xml:"cpu>numa>cell"`
+	}
+	if err := xml.Unmarshal([]byte(desc), &d); err != nil {
+		return false, err
+	}
+	return d.Cell != nil, nil
+}
+
+// resizeNUMACell rewrites the single NUMA cell's cpus and memory attributes
+// to match desc's current <vcpu>/<memory>, so a vcpu or memory change made
+// by Set doesn't leave a stale, inconsistent NUMA topology behind. desc is
+// expected to already reflect the requested change.
+func resizeNUMACell(desc string) (string, error) {
+	var r domainResources
+	if err := xml.Unmarshal([]byte(desc), &r); err != nil {
+		return "", err
+	}
+
+	cpuRange := vm.CPURange(r.VCPU)
+	memory := strconv.FormatUint(r.Memory, 10)
+
+	dec := xml.NewDecoder(bytes.NewReader([]byte(desc)))
+
+	var out bytes.Buffer
+	enc := xml.NewEncoder(&out)
+
+	found := false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		if t, ok := tok.(xml.StartElement); ok && t.Name.Local == "cell" {
+			found = true
+			for i, attr := range t.Attr {
+				switch attr.Name.Local {
+				case "cpus":
+					t.Attr[i].Value = cpuRange
+				case "memory":
+					t.Attr[i].Value = memory
+				}
+			}
+			tok = t
+		}
+
+		if err := enc.EncodeToken(tok); err != nil {
+			return "", err
+		}
+	}
+
+	if !found {
+		return "", fmt.Errorf("numa cell not found in domain XML")
+	}
+
+	if err := enc.Flush(); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// setElementText rewrites the text content of the first top-level child of
+// <domain> named elem. The rest of the document keeps its content but is
+// re-encoded token by token, so its formatting may differ from desc.
+func setElementText(desc, elem, value string) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader([]byte(desc)))
+
+	var out bytes.Buffer
+	enc := xml.NewEncoder(&out)
+
+	depth := 0
+	replacing := false
+	replaced := false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && t.Name.Local == elem {
+				replacing = true
+			}
+			if err := enc.EncodeToken(t); err != nil {
+				return "", err
+			}
+		case xml.CharData:
+			if replacing && !replaced {
+				if err := enc.EncodeToken(xml.CharData([]byte(value))); err != nil {
+					return "", err
+				}
+				replaced = true
+				continue
+			}
+			if err := enc.EncodeToken(t); err != nil {
+				return "", err
+			}
+		case xml.EndElement:
+			if depth == 2 && t.Name.Local == elem {
+				replacing = false
+			}
+			depth--
+			if err := enc.EncodeToken(t); err != nil {
+				return "", err
+			}
+		default:
+			if err := enc.EncodeToken(tok); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if !replaced {
+		return "", fmt.Errorf("element %v not found in domain XML", elem)
+	}
+
+	if err := enc.Flush(); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
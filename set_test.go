@@ -0,0 +1,76 @@
+package box
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+const testDomainXML = `<domain type='kvm'>
+  <name>test-domain</name>
+  <memory unit='KiB'>2097152</memory>
+  <currentMemory unit='KiB'>2097152</currentMemory>
+  <vcpu placement='static'>2</vcpu>
+  <devices>
+    <disk type='file' device='disk'>
+      <source file='/var/lib/box/test-domain/disk.qcow2'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <disk type='file' device='cdrom'>
+      <source file='/var/lib/box/test-domain/seed.iso'/>
+      <target dev='sda' bus='sata'/>
+    </disk>
+  </devices>
+</domain>
+`
+
+func TestSetElementText(t *testing.T) {
+	out, err := setElementText(testDomainXML, "vcpu", "4")
+	if err != nil {
+		t.Fatalf("setElementText: %v", err)
+	}
+
+	got, err := firstVCPUText(out)
+	if err != nil {
+		t.Fatalf("firstVCPUText: %v", err)
+	}
+	if got != "4" {
+		t.Errorf("vcpu text = %v, want 4", got)
+	}
+}
+
+func TestSetElementTextMissingElement(t *testing.T) {
+	if _, err := setElementText(testDomainXML, "nonexistent", "1"); err == nil {
+		t.Error("setElementText with missing element = nil error, want error")
+	}
+}
+
+func TestFirstDiskSource(t *testing.T) {
+	path, err := firstDiskSource(testDomainXML)
+	if err != nil {
+		t.Fatalf("firstDiskSource: %v", err)
+	}
+
+	want := "/var/lib/box/test-domain/disk.qcow2"
+	if path != want {
+		t.Errorf("firstDiskSource() = %v, want %v", path, want)
+	}
+}
+
+func TestFirstDiskSourceNoFileBackedDisk(t *testing.T) {
+	const desc = `<domain><devices><disk type='block' device='disk'><source dev='/dev/vg/lv'/></disk></devices></domain>`
+
+	if _, err := firstDiskSource(desc); err == nil {
+		t.Error("firstDiskSource with no file-backed disk = nil error, want error")
+	}
+}
+
+// firstVCPUText extracts the <vcpu> text content for assertions.
+func firstVCPUText(desc string) (string, error) {
+	var d struct {
+		VCPU string `xml:"vcpu"`
+	}
+	if err := xml.Unmarshal([]byte(desc), &d); err != nil {
+		return "", err
+	}
+	return d.VCPU, nil
+}
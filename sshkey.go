@@ -0,0 +1,45 @@
+package box
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// generateSSHKeyPair creates a new ed25519 keypair under dir, writing the
+// private key to id_ed25519 (mode 0600) and the public key to
+// id_ed25519.pub. It returns the public key in authorized_keys format and
+// the path to the private key.
+func generateSSHKeyPair(dir string) (pubKey, privKeyPath string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", "", err
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "box")
+	if err != nil {
+		return "", "", err
+	}
+
+	privKeyPath = filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(privKeyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		return "", "", err
+	}
+
+	authorizedKey := ssh.MarshalAuthorizedKey(sshPub)
+	if err := os.WriteFile(privKeyPath+".pub", authorizedKey, 0o644); err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(string(authorizedKey)), privKeyPath, nil
+}
@@ -0,0 +1,30 @@
+package box
+
+import (
+	"github.com/libvirt/libvirt-go"
+)
+
+// Up starts the defined domain named name. It is a no-op if the domain is
+// already running.
+func Up(name string) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	dom, err := conn.LookupDomainByName(name)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return err
+	}
+	if state == libvirt.DOMAIN_RUNNING {
+		return nil
+	}
+
+	return dom.Create()
+}
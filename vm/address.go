@@ -0,0 +1,34 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/libvirt/libvirt-go"
+)
+
+// GuestAddress returns the first IPv4 address reported for dom, preferring
+// the QEMU guest agent as a source and falling back to the DHCP lease
+// database.
+func GuestAddress(dom *libvirt.Domain) (string, error) {
+	sources := []libvirt.DomainInterfaceAddressesSource{
+		libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_AGENT,
+		libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_LEASE,
+	}
+
+	for _, src := range sources {
+		ifaces, err := dom.ListAllInterfaceAddresses(src)
+		if err != nil {
+			continue
+		}
+
+		for _, iface := range ifaces {
+			for _, addr := range iface.Addrs {
+				if addr.Type == int(libvirt.IP_ADDR_TYPE_IPV4) {
+					return addr.Addr, nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no guest address found")
+}
@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/libvirt/libvirt-go"
+)
+
+// consoleEscape is the byte (Ctrl-]) that disconnects from the console
+// without closing the calling terminal, matching telnet/virsh console
+// convention.
+const consoleEscape = 0x1d
+
+// Console attaches to dom's primary serial console and copies bytes
+// bidirectionally between it and in/out until either side returns an error
+// (including io.EOF) or in sends the escape character Ctrl-] (0x1d), which
+// disconnects cleanly without an error.
+func Console(dom *libvirt.Domain, in io.Reader, out io.Writer) error {
+	conn, err := dom.GetConnect()
+	if err != nil {
+		return err
+	}
+
+	stream, err := conn.NewStream(0)
+	if err != nil {
+		return err
+	}
+	defer stream.Free()
+
+	if err := dom.OpenConsole("", stream, 0); err != nil {
+		return err
+	}
+
+	errc := make(chan error, 2)
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				chunk := buf[:n]
+				if i := bytes.IndexByte(chunk, consoleEscape); i >= 0 {
+					if i > 0 {
+						if _, werr := stream.Send(chunk[:i]); werr != nil {
+							errc <- werr
+							return
+						}
+					}
+					errc <- nil
+					return
+				}
+				if _, werr := stream.Send(chunk); werr != nil {
+					errc <- werr
+					return
+				}
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := stream.Recv(buf)
+			if n > 0 {
+				if _, werr := out.Write(buf[:n]); werr != nil {
+					errc <- werr
+					return
+				}
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	return <-errc
+}
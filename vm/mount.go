@@ -0,0 +1,177 @@
+package vm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/libvirt/libvirt-go"
+)
+
+// virtiofsMinVersion is the first libvirt release (6.2.0) with virtiofs
+// filesystem device support, encoded the way GetLibVersion reports it:
+// 1,000,000 * major + 1,000 * minor + release.
+const virtiofsMinVersion = 6002000
+
+// MountSpec describes a single host-to-guest shared folder.
+type MountSpec struct {
+	// Type is "virtiofs" or "9p". Create falls back to "9p" automatically
+	// when the connected libvirt is too old for virtiofs.
+	Type   string
+	Source string
+	Target string
+}
+
+// ParseMountSpec parses a --mount flag value of the form
+// "type=virtiofs,source=/host/path,target=/mnt/foo".
+func ParseMountSpec(s string) (MountSpec, error) {
+	m := MountSpec{Type: "virtiofs"}
+
+	for _, field := range strings.Split(s, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return MountSpec{}, fmt.Errorf("invalid mount field: %v", field)
+		}
+
+		switch kv[0] {
+		case "type":
+			m.Type = kv[1]
+		case "source":
+			m.Source = kv[1]
+		case "target":
+			m.Target = kv[1]
+		default:
+			return MountSpec{}, fmt.Errorf("unknown mount field: %v", kv[0])
+		}
+	}
+
+	if m.Type != "virtiofs" && m.Type != "9p" {
+		return MountSpec{}, fmt.Errorf("unsupported mount type: %v", m.Type)
+	}
+	if m.Source == "" || m.Target == "" {
+		return MountSpec{}, fmt.Errorf("mount requires source and target")
+	}
+
+	return m, nil
+}
+
+// Tag returns the libvirt filesystem target tag used to identify m inside
+// the guest, derived from its mount point.
+func (m MountSpec) Tag() string {
+	return "mnt" + strings.ReplaceAll(m.Target, "/", "_")
+}
+
+// SupportsVirtiofs reports whether conn's libvirt is new enough to define
+// virtiofs filesystem devices.
+func SupportsVirtiofs(conn *libvirt.Connect) bool {
+	v, err := conn.GetLibVersion()
+	if err != nil {
+		return false
+	}
+	return v >= virtiofsMinVersion
+}
+
+// HasSharedMemoryBacking reports whether dom's definition already declares
+// the <memoryBacking><access mode='shared'/></memoryBacking> that virtiofs
+// requires. It is set at boot time by Create and cannot be hot-added, so
+// this tells a caller whether a virtiofs device can be live-attached to dom
+// at all.
+func HasSharedMemoryBacking(dom *libvirt.Domain) (bool, error) {
+	desc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return false, err
+	}
+
+	var d struct {
+		Access *struct {
+			Mode string `xml:"mode,attr"`
+		} `xml:"memoryBacking>access"`
+	}
+	if err := xml.Unmarshal([]byte(desc), &d); err != nil {
+		return false, err
+	}
+
+	return d.Access != nil && d.Access.Mode == "shared", nil
+}
+
+// FilesystemXML renders the <filesystem> device for m, falling back to 9p
+// access semantics when m.Type is "9p".
+func FilesystemXML(m MountSpec) string {
+	if m.Type == "9p" {
+		return fmt.Sprintf(`    <filesystem type='mount' accessmode='mapped'>
+      <driver type='path'/>
+      <source dir='%s'/>
+      <target dir='%s'/>
+    </filesystem>
+`, m.Source, m.Tag())
+	}
+
+	return fmt.Sprintf(`    <filesystem type='mount' accessmode='passthrough'>
+      <driver type='virtiofs'/>
+      <source dir='%s'/>
+      <target dir='%s'/>
+    </filesystem>
+`, m.Source, m.Tag())
+}
+
+// MemoryBackingXML renders the shared memory backing virtiofs requires.
+func MemoryBackingXML() string {
+	return "  <memoryBacking>\n    <access mode='shared'/>\n  </memoryBacking>\n"
+}
+
+// CPURange renders the vcpu range a single NUMA cell spans, e.g. "0-3" for
+// 4 vcpus.
+func CPURange(vcpus uint) string {
+	if vcpus > 1 {
+		return fmt.Sprintf("0-%d", vcpus-1)
+	}
+	return "0"
+}
+
+// NUMAXML renders a single-cell NUMA topology with shared memory access, as
+// virtiofs requires.
+func NUMAXML(memoryKiB uint64, vcpus uint) string {
+	return fmt.Sprintf(`  <cpu mode='host-passthrough'>
+    <numa>
+      <cell id='0' cpus='%s' memory='%d' unit='KiB' memAccess='shared'/>
+    </numa>
+  </cpu>
+`, CPURange(vcpus), memoryKiB)
+}
+
+// SystemdMountUnit renders a systemd .mount unit that mounts m at boot, and
+// returns its unit name (suitable for a filename under
+// /etc/systemd/system/).
+func SystemdMountUnit(m MountSpec) (unitName, content string) {
+	unitName = systemdEscape(m.Target) + ".mount"
+
+	fsType := "virtiofs"
+	options := "defaults"
+	if m.Type == "9p" {
+		fsType = "9p"
+		options = "trans=virtio,version=9p2000.L"
+	}
+
+	content = fmt.Sprintf(`[Unit]
+Description=Mount %s shared folder
+
+[Mount]
+What=%s
+Where=%s
+Type=%s
+Options=%s
+
+[Install]
+WantedBy=multi-user.target
+`, m.Source, m.Tag(), m.Target, fsType, options)
+
+	return unitName, content
+}
+
+// systemdEscape approximates systemd-escape --path for the simple absolute
+// paths box mount targets accept: a leading slash is dropped and remaining
+// slashes become dashes.
+func systemdEscape(path string) string {
+	trimmed := strings.Trim(path, "/")
+	return strings.ReplaceAll(trimmed, "/", "-")
+}
@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMountSpec(t *testing.T) {
+	m, err := ParseMountSpec("type=virtiofs,source=/host/src,target=/mnt/src")
+	if err != nil {
+		t.Fatalf("ParseMountSpec: %v", err)
+	}
+
+	want := MountSpec{Type: "virtiofs", Source: "/host/src", Target: "/mnt/src"}
+	if m != want {
+		t.Errorf("ParseMountSpec() = %+v, want %+v", m, want)
+	}
+}
+
+func TestParseMountSpecDefaultsToVirtiofs(t *testing.T) {
+	m, err := ParseMountSpec("source=/host/src,target=/mnt/src")
+	if err != nil {
+		t.Fatalf("ParseMountSpec: %v", err)
+	}
+	if m.Type != "virtiofs" {
+		t.Errorf("Type = %v, want virtiofs", m.Type)
+	}
+}
+
+func TestParseMountSpecErrors(t *testing.T) {
+	cases := []string{
+		"type=nfs,source=/a,target=/b",
+		"source=/a",
+		"target=/b",
+		"bogus",
+		"type=virtiofs,what=/a",
+	}
+
+	for _, s := range cases {
+		if _, err := ParseMountSpec(s); err == nil {
+			t.Errorf("ParseMountSpec(%q) = nil error, want error", s)
+		}
+	}
+}
+
+func TestCPURange(t *testing.T) {
+	cases := []struct {
+		vcpus uint
+		want  string
+	}{
+		{1, "0"},
+		{2, "0-1"},
+		{4, "0-3"},
+	}
+
+	for _, c := range cases {
+		if got := CPURange(c.vcpus); got != c.want {
+			t.Errorf("CPURange(%d) = %v, want %v", c.vcpus, got, c.want)
+		}
+	}
+}
+
+func TestNUMAXML(t *testing.T) {
+	got := NUMAXML(2097152, 4)
+
+	for _, want := range []string{"cpus='0-3'", "memory='2097152'", "memAccess='shared'"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("NUMAXML() missing %q:\n%s", want, got)
+		}
+	}
+}
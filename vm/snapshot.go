@@ -0,0 +1,194 @@
+// Package vm contains helpers that operate directly on libvirt domains,
+// below the level of the box command surface.
+package vm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/libvirt/libvirt-go"
+)
+
+// Snapshot describes a single domain snapshot.
+type Snapshot struct {
+	Name        string
+	Description string
+	Created     time.Time
+}
+
+// snapshotXML mirrors the subset of the libvirt domainsnapshot schema this
+// package reads and writes.
+type snapshotXML struct {
+	XMLName      xml.Name `xml:"domainsnapshot"`
+	Name         string   `xml:"name"`
+	Description  string   `xml:"description,omitempty"`
+	CreationTime string   `xml:"creationTime,omitempty"`
+}
+
+// CreateSnapshot creates a new snapshot named snapshot of the domain named
+// domain, recording description in the snapshot metadata.
+func CreateSnapshot(domain, snapshot, description string) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	dom, err := conn.LookupDomainByName(domain)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	desc := snapshotXML{
+		Name:        snapshot,
+		Description: description,
+	}
+
+	b, err := xml.Marshal(desc)
+	if err != nil {
+		return err
+	}
+
+	snap, err := dom.CreateSnapshotXML(string(b), 0)
+	if err != nil {
+		return err
+	}
+	defer snap.Free()
+
+	return nil
+}
+
+// ListSnapshots returns every snapshot of the domain named domain, in the
+// order reported by libvirt.
+func ListSnapshots(domain string) ([]Snapshot, error) {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return nil, err
+	}
+
+	dom, err := conn.LookupDomainByName(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer dom.Free()
+
+	names, err := dom.SnapshotListNames(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, name := range names {
+		snap, err := dom.SnapshotLookupByName(name, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		desc, err := snap.GetXMLDesc(0)
+		snap.Free()
+		if err != nil {
+			return nil, err
+		}
+
+		var x snapshotXML
+		if err := xml.Unmarshal([]byte(desc), &x); err != nil {
+			return nil, err
+		}
+
+		var created time.Time
+		if x.CreationTime != "" {
+			secs, err := strconv.ParseInt(x.CreationTime, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			created = time.Unix(secs, 0)
+		}
+
+		snapshots = append(snapshots, Snapshot{
+			Name:        x.Name,
+			Description: x.Description,
+			Created:     created,
+		})
+	}
+
+	return snapshots, nil
+}
+
+// RevertSnapshot reverts the domain named domain to the snapshot named
+// snapshot. A running domain is suspended before the revert; afterward, the
+// domain is resumed only if the revert actually left it paused, since the
+// snapshot may have recorded a different state (e.g. shut off) than the
+// domain was in beforehand.
+func RevertSnapshot(domain, snapshot string) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	dom, err := conn.LookupDomainByName(domain)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	snap, err := dom.SnapshotLookupByName(snapshot, 0)
+	if err != nil {
+		return err
+	}
+	defer snap.Free()
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return err
+	}
+	if state == libvirt.DOMAIN_RUNNING {
+		if err := dom.Suspend(); err != nil {
+			return err
+		}
+	}
+
+	if err := snap.RevertToSnapshot(0); err != nil {
+		return err
+	}
+
+	state, _, err = dom.GetState()
+	if err != nil {
+		return err
+	}
+	if state == libvirt.DOMAIN_PAUSED {
+		if err := dom.Resume(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteSnapshot removes the snapshot named snapshot from the domain named
+// domain.
+func DeleteSnapshot(domain, snapshot string) error {
+	conn, err := libvirt.NewConnect("")
+	if err != nil {
+		return err
+	}
+
+	dom, err := conn.LookupDomainByName(domain)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	snap, err := dom.SnapshotLookupByName(snapshot, 0)
+	if err != nil {
+		return err
+	}
+	defer snap.Free()
+
+	if err := snap.Delete(0); err != nil {
+		return fmt.Errorf("delete snapshot %v: %w", snapshot, err)
+	}
+
+	return nil
+}